@@ -0,0 +1,118 @@
+// Package config defines the on-disk TOML format for the runv containerd
+// daemon and the defaults it falls back to when no config file is given.
+package config
+
+import (
+	"github.com/BurntSushi/toml"
+)
+
+// DefaultPath is where the daemon looks for its config file when neither
+// -config nor -c is given on the command line.
+const DefaultPath = "/etc/runv/config.toml"
+
+// DefaultDebugAddress is where /debug/pprof, /debug/vars and /metrics are
+// served when no config file is found. A config file that has no [debug]
+// address of its own disables the debug listener.
+const DefaultDebugAddress = "127.0.0.1:7070"
+
+// GRPCConfig configures the daemon's task/container API listener(s). Each
+// entry in Listeners is a -listen style address (a bare path for a unix
+// socket, or `tcp://host:port`); Address is kept as the single-listener
+// shorthand most configs use and, when set, is treated as one more entry.
+type GRPCConfig struct {
+	Address   string   `toml:"address"`
+	Listeners []string `toml:"listeners"`
+	TLSCert   string   `toml:"tls_cert"`
+	TLSKey    string   `toml:"tls_key"`
+	TLSCA     string   `toml:"tls_ca"`
+}
+
+// Addresses returns every address the daemon should listen on, combining
+// the single Address shorthand with the Listeners list.
+func (g GRPCConfig) Addresses() []string {
+	addrs := g.Listeners
+	if g.Address != "" {
+		addrs = append([]string{g.Address}, addrs...)
+	}
+	return addrs
+}
+
+// DebugConfig configures the debug HTTP listener (pprof/expvar/metrics).
+type DebugConfig struct {
+	Address string `toml:"address"`
+	Level   string `toml:"level"`
+	Format  string `toml:"format"`
+}
+
+// HypervisorConfig carries the driver/kernel/initrd/template settings that
+// used to only be reachable via CLI flags.
+type HypervisorConfig struct {
+	Driver   string `toml:"driver"`
+	Kernel   string `toml:"kernel"`
+	Initrd   string `toml:"initrd"`
+	Template string `toml:"template"`
+	// Factory selects the base.Factory the same way the -factory CLI flag
+	// does, e.g. "direct", "template" or "template+cache=8" for a
+	// pre-warmed pool of 8 VMs.
+	Factory string `toml:"factory"`
+}
+
+// Config is the top-level shape of /etc/runv/config.toml.
+type Config struct {
+	GRPC       GRPCConfig       `toml:"grpc"`
+	Debug      DebugConfig      `toml:"debug"`
+	Hypervisor HypervisorConfig `toml:"hypervisor"`
+
+	// Plugins holds each `[plugins."io.runv.factory.template"]` section
+	// undecoded; the plugin named by the key decodes its own sub-tree from
+	// plugin.InitContext.Config.
+	Plugins map[string]toml.Primitive `toml:"plugins"`
+
+	// md is kept around so callers can hand individual plugins their
+	// toml.Primitive without re-parsing the file.
+	md toml.MetaData
+}
+
+// Load reads and parses the config file at path. A missing file is returned
+// as an error that os.IsNotExist recognises, so the caller can fall back to
+// Default().
+func Load(path string) (*Config, error) {
+	var c Config
+	md, err := toml.DecodeFile(path, &c)
+	if err != nil {
+		return nil, err
+	}
+	c.md = md
+	return &c, nil
+}
+
+// PluginConfig returns the undecoded config tree for the plugin registered
+// under uri, or the zero Primitive if the file has no section for it.
+func (c *Config) PluginConfig(uri string) toml.Primitive {
+	return c.Plugins[uri]
+}
+
+// Meta returns the toml.MetaData produced while decoding the file, which
+// plugin.InitContext needs in order to decode its own Primitive.
+func (c *Config) Meta() toml.MetaData {
+	return c.md
+}
+
+// Default returns the configuration the daemon used before config.toml
+// existed, built from the equivalent CLI flags.
+func Default(stateDir, listen, driver, kernel, initrd, template string) *Config {
+	return &Config{
+		GRPC: GRPCConfig{Address: listen},
+		Debug: DebugConfig{
+			Address: DefaultDebugAddress,
+			Level:   "info",
+			Format:  "text",
+		},
+		Hypervisor: HypervisorConfig{
+			Driver:   driver,
+			Kernel:   kernel,
+			Initrd:   initrd,
+			Template: template,
+		},
+	}
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGRPCConfigAddresses(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  GRPCConfig
+		want []string
+	}{
+		{
+			name: "address only",
+			cfg:  GRPCConfig{Address: "/run/runv-containerd/containerd.sock"},
+			want: []string{"/run/runv-containerd/containerd.sock"},
+		},
+		{
+			name: "listeners only",
+			cfg:  GRPCConfig{Listeners: []string{"tcp://0.0.0.0:5432"}},
+			want: []string{"tcp://0.0.0.0:5432"},
+		},
+		{
+			name: "address is prepended to listeners",
+			cfg: GRPCConfig{
+				Address:   "/run/runv-containerd/containerd.sock",
+				Listeners: []string{"tcp://0.0.0.0:5432"},
+			},
+			want: []string{"/run/runv-containerd/containerd.sock", "tcp://0.0.0.0:5432"},
+		},
+		{
+			name: "empty",
+			cfg:  GRPCConfig{},
+			want: nil,
+		},
+	}
+	for _, c := range cases {
+		if got := c.cfg.Addresses(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: Addresses() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -3,23 +3,29 @@ package containerd
 import (
 	"encoding/json"
 	"flag"
+	"fmt"
 	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/codegangsta/cli"
 	"github.com/docker/containerd/api/grpc/types"
-	"github.com/docker/containerd/osutils"
 	"github.com/golang/glog"
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/hyperhq/runv/containerd/api/grpc/server"
+	runvconfig "github.com/hyperhq/runv/containerd/config"
 	"github.com/hyperhq/runv/driverloader"
 	"github.com/hyperhq/runv/factory"
+	cachedfactory "github.com/hyperhq/runv/factory/cached"
 	singlefactory "github.com/hyperhq/runv/factory/single"
 	templatefactory "github.com/hyperhq/runv/factory/template"
 	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/plugin"
 	"github.com/hyperhq/runv/supervisor"
 	templatecore "github.com/hyperhq/runv/template"
 	"google.golang.org/grpc"
@@ -49,12 +55,33 @@ var ContainerdCommand = cli.Command{
 		cli.StringFlag{
 			Name:  "listen,l",
 			Value: defaultGRPCEndpoint,
-			Usage: "Address on which GRPC API will listen",
+			Usage: "Address on which GRPC API will listen, e.g. /run/runv-containerd/containerd.sock or tcp://0.0.0.0:5432",
+		},
+		cli.StringFlag{
+			Name:  "tls-cert",
+			Usage: "TLS certificate for the GRPC API, requires -tls-key and -tls-ca",
+		},
+		cli.StringFlag{
+			Name:  "tls-key",
+			Usage: "TLS private key for the GRPC API, requires -tls-cert and -tls-ca",
+		},
+		cli.StringFlag{
+			Name:  "tls-ca",
+			Usage: "TLS CA used to verify GRPC API client certificates, requires -tls-cert and -tls-key",
 		},
 		cli.BoolFlag{
 			Name:  "solo-namespaced",
 			Usage: "launch as a solo namespaced for shared containers",
 		},
+		cli.StringFlag{
+			Name:  "config,c",
+			Value: runvconfig.DefaultPath,
+			Usage: "path to the TOML config file",
+		},
+		cli.StringFlag{
+			Name:  "factory",
+			Usage: "factory selection, e.g. \"direct\", \"template\" or \"template+cache=8\" for a pre-warmed pool of 8 VMs",
+		},
 	},
 	Action: func(context *cli.Context) {
 		driver := context.GlobalString("driver")
@@ -101,18 +128,23 @@ var ContainerdCommand = cli.Command{
 			os.Exit(1)
 		}
 
+		cfg, err := loadConfig(context.String("config"), stateDir, context.String("listen"), driver, kernel, initrd, template)
+		if err != nil {
+			glog.Infof("%v", err)
+			os.Exit(1)
+		}
+
 		hypervisor.InterfaceCount = 0
-		var err error
-		if hypervisor.HDriver, err = driverloader.Probe(driver); err != nil {
+		if hypervisor.HDriver, err = loadDriver(cfg, driver); err != nil {
 			glog.V(1).Infof("%s\n", err.Error())
 			os.Exit(1)
 		}
 
-		var f factory.Factory
-		if template != "" {
-			f = singlefactory.New(templatefactory.NewFromExisted(tconfig))
-		} else {
-			f = factory.NewFromConfigs(kernel, initrd, nil)
+		factorySpec := firstNonEmpty(context.String("factory"), cfg.Hypervisor.Factory)
+		f, err := loadFactory(cfg, template, tconfig, kernel, initrd, factorySpec)
+		if err != nil {
+			glog.Infof("%v", err)
+			os.Exit(1)
 		}
 		sv, err := supervisor.New(stateDir, containerdDir, f)
 		if err != nil {
@@ -123,8 +155,23 @@ var ContainerdCommand = cli.Command{
 		if context.Bool("solo-namespaced") {
 			go namespaceShare(sv, containerdDir, stateDir)
 		}
-                //获取命令行指定的监听地址。这个就是一个gRPC服务。
-		if err = daemon(sv, context.String("listen")); err != nil {
+
+		if err = serveDebugAPI(cfg.Debug.Address); err != nil {
+			glog.Infof("%v", err)
+			os.Exit(1)
+		}
+
+		//监听地址取自配置文件 [grpc] 里的 address/listeners，没有配置时才用命令行的 -listen。这个就是一个gRPC服务，支持多个监听地址。
+		tlsCfg := TLSConfig{
+			CertFile: firstNonEmpty(context.String("tls-cert"), cfg.GRPC.TLSCert),
+			KeyFile:  firstNonEmpty(context.String("tls-key"), cfg.GRPC.TLSKey),
+			CAFile:   firstNonEmpty(context.String("tls-ca"), cfg.GRPC.TLSCA),
+		}
+		addresses := cfg.GRPC.Addresses()
+		if len(addresses) == 0 {
+			addresses = []string{context.String("listen")}
+		}
+		if err = daemon(cfg, sv, addresses, tlsCfg); err != nil {
 			glog.Infof("%v", err)
 			os.Exit(1)
 		}
@@ -135,29 +182,37 @@ var ContainerdCommand = cli.Command{
 	},
 }
 
-func daemon(sv *supervisor.Supervisor, address string) error {
-	// setup a standard reaper so that we don't leave any zombies if we are still alive
-	// this is just good practice because we are spawning new processes
-	s := make(chan os.Signal, 2048)
-	signal.Notify(s, syscall.SIGCHLD, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+// firstNonEmpty returns the first non-empty string, letting a CLI flag
+// override the equivalent TOML setting.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func daemon(cfg *runvconfig.Config, sv *supervisor.Supervisor, addresses []string, tlsCfg TLSConfig) error {
+	// reaper.Default is intentionally not started here yet: it would own
+	// wait4(-1) for the whole process, but the qemu/hyperstart/network-setup
+	// exec.Cmd spawns in the hypervisor packages this daemon links against
+	// still call cmd.Start()/cmd.Wait() directly rather than going through
+	// reaper.Default.Start. Running both reapers at once means whichever
+	// loses the wait4(-1) race has its child reaped out from under it and
+	// Wait() return a bogus ECHILD. Switch this on once those call sites are
+	// migrated to reaper.Default.Start.
+	shutdown := make(chan os.Signal, 8)
+	signal.Notify(shutdown, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
 
-	server, err := startServer(address, sv)
+	server, err := startServer(cfg, addresses, sv, tlsCfg)
 	if err != nil {
 		return err
 	}
-	for ss := range s {
-		switch ss {
-		case syscall.SIGCHLD:
-			if _, err := osutils.Reap(); err != nil {
-				glog.Infof("containerd: reap child processes")
-			}
-		default:
-			glog.Infof("stopping containerd after receiving %s", ss)
-			time.Sleep(3 * time.Second) // TODO: fix it by proper way
-			server.Stop()
-			return nil
-		}
-	}
+	ss := <-shutdown
+	glog.Infof("stopping containerd after receiving %s", ss)
+	time.Sleep(3 * time.Second) // TODO: fix it by proper way
+	server.Stop()
 	return nil
 }
 
@@ -177,23 +232,197 @@ func namespaceShare(sv *supervisor.Supervisor, namespace, state string) {
 	}
 }
 
-func startServer(address string, sv *supervisor.Supervisor) (*grpc.Server, error) {
-	if err := os.RemoveAll(address); err != nil {
-		return nil, err
-	}
-	l, err := net.Listen(defaultListenType, address)
+// loadConfig reads the TOML config file at path, falling back to the
+// defaults derived from the CLI flags when the file does not exist so that
+// -config stays entirely optional.
+func loadConfig(path, stateDir, listen, driver, kernel, initrd, template string) (*runvconfig.Config, error) {
+	cfg, err := runvconfig.Load(path)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return runvconfig.Default(stateDir, listen, driver, kernel, initrd, template), nil
+		}
 		return nil, err
 	}
-	//建立连接，监听的是一个socket文件/run/runv-containerd/containerd.sock
+	return cfg, nil
+}
+
+// loadFactory picks the base.Factory to run containers on. If the config
+// file registers a `[plugins."io.runv.factory.*"]` section it takes
+// precedence; otherwise it falls back to the template/direct selection the
+// daemon has always done from its CLI flags, optionally wrapped in a
+// pre-warmed pool when factorySpec asks for e.g. "template+cache=8" --
+// whether that came from -factory or the TOML [hypervisor] factory field,
+// the CLI flag winning when both are set.
+func loadFactory(cfg *runvconfig.Config, template string, tconfig *templatecore.TemplateVmConfig, kernel, initrd, factorySpec string) (factory.Factory, error) {
+	for uri := range cfg.Plugins {
+		r, err := plugin.Get(uri)
+		if err != nil {
+			return nil, err
+		}
+		if r.Type != plugin.FactoryPlugin {
+			continue
+		}
+		out, err := r.Init(&plugin.InitContext{
+			Config: cfg.PluginConfig(uri),
+			Meta:   cfg.Meta(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		f, ok := out.(factory.Factory)
+		if !ok {
+			return nil, fmt.Errorf("plugin %s did not return a factory.Factory", uri)
+		}
+		return f, nil
+	}
+
+	var f factory.Factory
+	if template != "" {
+		f = singlefactory.New(templatefactory.NewFromExisted(tconfig))
+	} else {
+		f = factory.NewFromConfigs(kernel, initrd, nil)
+	}
+
+	if cacheSize := parseCacheSize(factorySpec); cacheSize > 0 {
+		f = cachedfactory.New(f, cacheSize, cacheSize/2, 10*time.Minute)
+	}
+	return f, nil
+}
+
+// loadDriver picks the hypervisor.HypervisorDriver to run containers on. If
+// the config file registers a `[plugins."io.runv.driver.*"]` section it
+// takes precedence, the same way loadFactory prefers a registered
+// io.runv.factory.* plugin; otherwise it falls back to the usual
+// driverloader.Probe(driver) autodetection.
+//
+// drivers.go registers qemu/kvm/xen/vbox as DriverPlugins so they can be
+// selected this way, though each still resolves through driverloader.Probe
+// under the hood -- the driver implementations themselves live outside this
+// tree, so the plugins are thin adapters rather than independent loaders.
+func loadDriver(cfg *runvconfig.Config, driver string) (hypervisor.HypervisorDriver, error) {
+	for uri := range cfg.Plugins {
+		r, err := plugin.Get(uri)
+		if err != nil {
+			return nil, err
+		}
+		if r.Type != plugin.DriverPlugin {
+			continue
+		}
+		out, err := r.Init(&plugin.InitContext{
+			Config: cfg.PluginConfig(uri),
+			Meta:   cfg.Meta(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		d, ok := out.(hypervisor.HypervisorDriver)
+		if !ok {
+			return nil, fmt.Errorf("plugin %s did not return a hypervisor.HypervisorDriver", uri)
+		}
+		return d, nil
+	}
+	return driverloader.Probe(driver)
+}
+
+// parseCacheSize reads the "+cache=N" suffix off a -factory value like
+// "template+cache=8", returning 0 when the flag does not ask for a pool.
+func parseCacheSize(factorySpec string) int {
+	const marker = "+cache="
+	i := strings.Index(factorySpec, marker)
+	if i < 0 {
+		return 0
+	}
+	n, err := strconv.Atoi(factorySpec[i+len(marker):])
+	if err != nil || n <= 0 {
+		return 0
+	}
+	return n
+}
+
+// grpcService is implemented by whatever a registered io.runv.grpc.*
+// plugin's InitFn returns; registerGRPCPlugins uses it to expose the plugin
+// on the same grpc.Server as the daemon's own API.
+type grpcService interface {
+	Register(*grpc.Server)
+}
+
+// registerGRPCPlugins exposes every config-registered io.runv.grpc.* plugin
+// on s, alongside the daemon's built-in API.
+//
+// drivers.go registers a standard grpc-go health service as "io.runv.grpc.health";
+// it is exposed only when the config file has a section for it, and without
+// a config file no plugin is exposed. Additional services can register the
+// same way without this file changing.
+func registerGRPCPlugins(cfg *runvconfig.Config, s *grpc.Server) error {
+	for uri := range cfg.Plugins {
+		r, err := plugin.Get(uri)
+		if err != nil {
+			return err
+		}
+		if r.Type != plugin.GRPCPlugin {
+			continue
+		}
+		out, err := r.Init(&plugin.InitContext{
+			Config: cfg.PluginConfig(uri),
+			Meta:   cfg.Meta(),
+		})
+		if err != nil {
+			return err
+		}
+		svc, ok := out.(grpcService)
+		if !ok {
+			return fmt.Errorf("plugin %s did not return a grpcService", uri)
+		}
+		svc.Register(s)
+	}
+	return nil
+}
+
+func startServer(cfg *runvconfig.Config, addresses []string, sv *supervisor.Supervisor, tlsCfg TLSConfig) (*grpc.Server, error) {
+	listeners := make([]net.Listener, 0, len(addresses))
+	for _, address := range addresses {
+		l, err := listen(address)
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(grpc_prometheus.UnaryServerInterceptor),
+		grpc.StreamInterceptor(grpc_prometheus.StreamServerInterceptor),
+	}
+	if tlsCfg.enabled() {
+		creds, err := serverCredentials(tlsCfg)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, creds)
+	}
+
+	//建立连接，默认监听的是一个socket文件/run/runv-containerd/containerd.sock，
+	//也可以用 -listen tcp://host:port 换成一个 TCP(+mTLS) 监听，还可以在 [grpc] 里配置多个监听地址
 	//docker启动的时候是将信息发送到这个socket
-	s := grpc.NewServer()
+	s := grpc.NewServer(opts...)
 	types.RegisterAPIServer(s, server.NewServer(sv))
-	go func() {
-		glog.Infof("containerd: grpc api on %s", address)
-		if err := s.Serve(l); err != nil {
-			glog.Infof("containerd: serve grpc error")
+	if err := registerGRPCPlugins(cfg, s); err != nil {
+		for _, opened := range listeners {
+			opened.Close()
 		}
-	}()
+		return nil, err
+	}
+	grpc_prometheus.Register(s)
+	for i, l := range listeners {
+		address, l := addresses[i], l
+		go func() {
+			glog.Infof("containerd: grpc api on %s", address)
+			if err := s.Serve(l); err != nil {
+				glog.Infof("containerd: serve grpc error on %s: %v", address, err)
+			}
+		}()
+	}
 	return s, nil
 }
@@ -0,0 +1,24 @@
+package containerd
+
+import "testing"
+
+func TestParseCacheSize(t *testing.T) {
+	cases := []struct {
+		spec string
+		want int
+	}{
+		{"template+cache=8", 8},
+		{"template+cache=1", 1},
+		{"template", 0},
+		{"direct", 0},
+		{"", 0},
+		{"template+cache=0", 0},
+		{"template+cache=-1", 0},
+		{"template+cache=notanumber", 0},
+	}
+	for _, c := range cases {
+		if got := parseCacheSize(c.spec); got != c.want {
+			t.Errorf("parseCacheSize(%q) = %d, want %d", c.spec, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package containerd
+
+import (
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// serveDebugAPI starts the daemon's second HTTP listener, the one that
+// exposes pprof, expvar and Prometheus metrics the same way upstream
+// containerd does, separate from the gRPC task/container API.
+func serveDebugAPI(address string) error {
+	if address == "" {
+		glog.Infof("containerd: debug listener disabled, no [debug] address configured")
+		return nil
+	}
+
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	m := http.NewServeMux()
+	m.HandleFunc("/debug/pprof/", pprof.Index)
+	m.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	m.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	m.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	m.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	m.Handle("/debug/vars", expvar.Handler())
+	m.Handle("/metrics", promhttp.Handler())
+
+	go func() {
+		glog.Infof("containerd: debug api on %s", address)
+		if err := http.Serve(l, m); err != nil {
+			glog.Infof("containerd: serve debug api error: %v", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,47 @@
+package containerd
+
+import (
+	"github.com/hyperhq/runv/driverloader"
+	"github.com/hyperhq/runv/plugin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// knownDrivers lists the hypervisor.HypervisorDriver names driverloader.Probe
+// already knows how to load, so each one can be selected from
+// `[plugins."io.runv.driver.<name>"]` in the config file as an alternative to
+// the -driver CLI flag / autodetection loadDriver otherwise falls back to.
+var knownDrivers = []string{"qemu", "kvm", "xen", "vbox"}
+
+func init() {
+	for _, name := range knownDrivers {
+		name := name
+		plugin.Register(&plugin.Registration{
+			Type: plugin.DriverPlugin,
+			ID:   name,
+			InitFn: func(ic *plugin.InitContext) (interface{}, error) {
+				return driverloader.Probe(name)
+			},
+		})
+	}
+
+	plugin.Register(&plugin.Registration{
+		Type: plugin.GRPCPlugin,
+		ID:   "health",
+		InitFn: func(ic *plugin.InitContext) (interface{}, error) {
+			return &healthPlugin{srv: health.NewServer()}, nil
+		},
+	})
+}
+
+// healthPlugin exposes the standard grpc-go health service on the daemon's
+// gRPC server, satisfying the grpcService interface registerGRPCPlugins
+// expects.
+type healthPlugin struct {
+	srv *health.Server
+}
+
+func (h *healthPlugin) Register(s *grpc.Server) {
+	grpc_health_v1.RegisterHealthServer(s, h.srv)
+}
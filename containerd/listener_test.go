@@ -0,0 +1,22 @@
+package containerd
+
+import "testing"
+
+func TestParseListenAddress(t *testing.T) {
+	cases := []struct {
+		address     string
+		wantNetwork string
+		wantAddr    string
+	}{
+		{"/run/runv-containerd/containerd.sock", "unix", "/run/runv-containerd/containerd.sock"},
+		{"tcp://0.0.0.0:5432", "tcp", "0.0.0.0:5432"},
+		{"unix:///run/runv-containerd/containerd.sock", "unix", "/run/runv-containerd/containerd.sock"},
+	}
+	for _, c := range cases {
+		network, addr := parseListenAddress(c.address)
+		if network != c.wantNetwork || addr != c.wantAddr {
+			t.Errorf("parseListenAddress(%q) = (%q, %q), want (%q, %q)",
+				c.address, network, addr, c.wantNetwork, c.wantAddr)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Package metrics holds the Prometheus collectors the runv containerd
+// daemon exposes on its debug listener, alongside the standard
+// go-metrics/pprof/expvar surfaces upstream containerd also serves.
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// VMBootDuration tracks how long it takes a factory to hand back a
+	// usable *hypervisor.Vm, in seconds, labeled by factory kind so a
+	// template+cache factory can be compared against a cold direct one.
+	VMBootDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "runv",
+		Subsystem: "hypervisor",
+		Name:      "vm_boot_duration_seconds",
+		Help:      "time spent by a factory producing a base VM",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"factory"})
+
+	// PooledVMs is the number of warm hypervisor.Vm instances currently
+	// sitting in a factory/cached pool, waiting to be handed to a
+	// container. It is incremented when the refill loop clones a VM into
+	// the pool and decremented the moment that VM leaves pool residency,
+	// whether that's because it was handed out (pool hit), discarded for
+	// sitting idle too long, or torn down by CloseFactory. It deliberately
+	// does not track VMs once they've been handed to a container: nothing
+	// in factory/cached observes a container's normal exit path, and a
+	// gauge that only ever grows is worse than one with a narrower, honest
+	// scope.
+	PooledVMs = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "runv",
+		Subsystem: "hypervisor",
+		Name:      "pooled_vms",
+		Help:      "number of warm VMs currently sitting in a factory/cached pool",
+	})
+
+	// FactoryHits/FactoryMisses count how often a pooling factory (e.g.
+	// factory/cached) could serve GetBaseVm from its warm pool versus
+	// having to fall back to a cold clone/boot.
+	FactoryHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "runv",
+		Subsystem: "hypervisor",
+		Name:      "factory_hits_total",
+		Help:      "GetBaseVm calls served from a warm pool",
+	}, []string{"factory"})
+
+	FactoryMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "runv",
+		Subsystem: "hypervisor",
+		Name:      "factory_misses_total",
+		Help:      "GetBaseVm calls that required a cold boot",
+	}, []string{"factory"})
+)
+
+func init() {
+	prometheus.MustRegister(VMBootDuration, PooledVMs, FactoryHits, FactoryMisses)
+}
@@ -0,0 +1,29 @@
+package containerd
+
+import (
+	"os"
+
+	"github.com/codegangsta/cli"
+	"github.com/golang/glog"
+	"github.com/hyperhq/runv/linux/shim"
+)
+
+// ShimCommand implements the io.containerd.runv.v2 task service: containerd
+// forks one of these per container and talks to it over the socket it
+// prints on stdout, rather than dialing runv's own daemon socket the way
+// ContainerdCommand's clients do.
+var ShimCommand = cli.Command{
+	Name:  "shim",
+	Usage: "start a containerd shim v2 (Task Service) for a single container",
+	Action: func(context *cli.Context) {
+		driver := context.GlobalString("driver")
+		kernel := context.GlobalString("kernel")
+		initrd := context.GlobalString("initrd")
+		template := context.GlobalString("template")
+
+		if err := shim.Run(context.Args(), driver, kernel, initrd, template); err != nil {
+			glog.Infof("%v", err)
+			os.Exit(1)
+		}
+	},
+}
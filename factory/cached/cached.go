@@ -0,0 +1,205 @@
+// Package cached wraps any base.Factory with a background-refilled pool of
+// pre-booted VMs, so GetBaseVm() can return instantly instead of paying a
+// clone/boot cost on the container's critical path.
+package cached
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/hyperhq/runv/containerd/metrics"
+	"github.com/hyperhq/runv/factory/base"
+	templatefactory "github.com/hyperhq/runv/factory/template"
+	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/plugin"
+	"github.com/hyperhq/runv/template"
+)
+
+func init() {
+	plugin.Register(&plugin.Registration{
+		Type: plugin.FactoryPlugin,
+		ID:   "cached",
+		InitFn: func(ic *plugin.InitContext) (interface{}, error) {
+			var cfg Config
+			if err := ic.Decode(&cfg); err != nil {
+				return nil, err
+			}
+			if cfg.TemplatePath == "" {
+				return nil, fmt.Errorf("factory/cached: template_path is required")
+			}
+			if cfg.Size <= 0 {
+				return nil, fmt.Errorf("factory/cached: size must be > 0")
+			}
+			f, err := os.Open(filepath.Join(cfg.TemplatePath, "config.json"))
+			if err != nil {
+				return nil, fmt.Errorf("factory/cached: open template config: %v", err)
+			}
+			defer f.Close()
+			var tconfig template.TemplateVmConfig
+			if err := json.NewDecoder(f).Decode(&tconfig); err != nil {
+				return nil, fmt.Errorf("factory/cached: parse template config: %v", err)
+			}
+			lowWater := cfg.LowWater
+			if lowWater <= 0 {
+				lowWater = cfg.Size / 2
+			}
+			maxIdle := time.Duration(cfg.MaxIdleSeconds) * time.Second
+			return New(templatefactory.NewFromExisted(&tconfig), cfg.Size, lowWater, maxIdle), nil
+		},
+	})
+}
+
+// Config is the `[plugins."io.runv.factory.cached"]` TOML shape: it pools a
+// pre-warmed template factory the same way -factory "template+cache=N" does
+// from the CLI.
+type Config struct {
+	TemplatePath   string `toml:"template_path"`
+	Size           int    `toml:"size"`
+	LowWater       int    `toml:"low_water"`
+	MaxIdleSeconds int    `toml:"max_idle_seconds"`
+}
+
+// item is a pool entry tagged with the time it was created, so GetBaseVm
+// can discard VMs that have sat idle past maxIdle.
+type item struct {
+	vm        *hypervisor.Vm
+	createdAt time.Time
+}
+
+type cachedFactory struct {
+	base base.Factory
+
+	size     int
+	lowWater int
+	maxIdle  time.Duration
+
+	pool    chan item
+	refill  chan struct{}
+	closing chan struct{}
+	closed  chan struct{}
+}
+
+// New wraps base with a pool of up to size pre-cloned VMs. Whenever the
+// pool drops to lowWater or below, a refill goroutine tops it back up in
+// the background; VMs that sit in the pool longer than maxIdle are
+// discarded and replaced rather than handed to a container. A zero maxIdle
+// disables the age-based eviction.
+func New(base base.Factory, size, lowWater int, maxIdle time.Duration) base.Factory {
+	if lowWater >= size {
+		lowWater = size - 1
+	}
+	f := &cachedFactory{
+		base:     base,
+		size:     size,
+		lowWater: lowWater,
+		maxIdle:  maxIdle,
+		pool:     make(chan item, size),
+		refill:   make(chan struct{}, 1),
+		closing:  make(chan struct{}),
+		closed:   make(chan struct{}),
+	}
+	go f.run()
+	f.triggerRefill()
+	return f
+}
+
+func (f *cachedFactory) Config() *hypervisor.BootConfig {
+	return f.base.Config()
+}
+
+// GetBaseVm returns a warm VM from the pool when one is available, and
+// otherwise falls back to a synchronous, cold call into the wrapped
+// factory. Either way it asks the refill loop to top the pool back up.
+//
+// A VM stops counting towards metrics.PooledVMs the moment this returns it:
+// GetBaseVm has no visibility into what the caller does with it afterwards,
+// so it only ever claims to track pool residency, not the VM's full
+// lifetime.
+func (f *cachedFactory) GetBaseVm() (vm *hypervisor.Vm, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.VMBootDuration.WithLabelValues("cached").Observe(time.Since(start).Seconds())
+		f.triggerRefill()
+	}()
+
+	for {
+		select {
+		case it := <-f.pool:
+			if f.maxIdle > 0 && time.Since(it.createdAt) > f.maxIdle {
+				glog.V(2).Infof("factory/cached: discarding idle VM %s", it.vm.Id)
+				it.vm.Kill()
+				metrics.PooledVMs.Dec()
+				continue
+			}
+			metrics.FactoryHits.WithLabelValues("cached").Inc()
+			metrics.PooledVMs.Dec()
+			return it.vm, nil
+		default:
+			metrics.FactoryMisses.WithLabelValues("cached").Inc()
+			return f.base.GetBaseVm()
+		}
+	}
+}
+
+// CloseFactory stops the refill loop, drains and tears down every VM left
+// in the pool, then closes the wrapped factory.
+func (f *cachedFactory) CloseFactory() {
+	close(f.closing)
+	<-f.closed
+	for {
+		select {
+		case it := <-f.pool:
+			it.vm.Kill()
+			metrics.PooledVMs.Dec()
+		default:
+			f.base.CloseFactory()
+			return
+		}
+	}
+}
+
+func (f *cachedFactory) triggerRefill() {
+	select {
+	case f.refill <- struct{}{}:
+	default:
+	}
+}
+
+// run is the single background goroutine that clones new VMs into the pool
+// whenever it drops to the low-water mark, until CloseFactory stops it.
+func (f *cachedFactory) run() {
+	defer close(f.closed)
+	for {
+		select {
+		case <-f.closing:
+			return
+		case <-f.refill:
+		}
+
+		if len(f.pool) > f.lowWater {
+			continue
+		}
+
+		for len(f.pool) < f.size {
+			vm, err := f.base.GetBaseVm()
+			if err != nil {
+				glog.Infof("factory/cached: refill failed: %v", err)
+				break
+			}
+			select {
+			case f.pool <- item{vm: vm, createdAt: time.Now()}:
+				metrics.PooledVMs.Inc()
+			case <-f.closing:
+				vm.Kill()
+				return
+			default:
+				// pool filled up while we were cloning
+				vm.Kill()
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package cached
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperhq/runv/hypervisor"
+)
+
+// fakeFactory is a base.Factory that hands out a fresh zero-value VM on
+// every call, cheaply enough that the refill loop's timing is not a test
+// flake risk.
+type fakeFactory struct{}
+
+func (fakeFactory) Config() *hypervisor.BootConfig { return nil }
+
+func (fakeFactory) GetBaseVm() (*hypervisor.Vm, error) { return &hypervisor.Vm{}, nil }
+
+func (fakeFactory) CloseFactory() {}
+
+func TestNewFillsPoolToSize(t *testing.T) {
+	const size = 8
+	f := New(fakeFactory{}, size, size/2, 0)
+	cf := f.(*cachedFactory)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(cf.pool) < size && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := len(cf.pool); got != size {
+		t.Fatalf("pool depth = %d, want %d (size)", got, size)
+	}
+}
@@ -1,17 +1,53 @@
 package template
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/golang/glog"
+	"github.com/hyperhq/runv/containerd/metrics"
 	"github.com/hyperhq/runv/factory/base"
 	"github.com/hyperhq/runv/factory/direct"
 	"github.com/hyperhq/runv/hypervisor"
 	"github.com/hyperhq/runv/hypervisor/pod"
+	"github.com/hyperhq/runv/plugin"
 	"github.com/hyperhq/runv/template"
 )
 
+func init() {
+	plugin.Register(&plugin.Registration{
+		Type: plugin.FactoryPlugin,
+		ID:   "template",
+		InitFn: func(ic *plugin.InitContext) (interface{}, error) {
+			var cfg Config
+			if err := ic.Decode(&cfg); err != nil {
+				return nil, err
+			}
+			if cfg.TemplatePath == "" {
+				return nil, fmt.Errorf("factory/template: template_path is required")
+			}
+			f, err := os.Open(filepath.Join(cfg.TemplatePath, "config.json"))
+			if err != nil {
+				return nil, fmt.Errorf("factory/template: open template config: %v", err)
+			}
+			defer f.Close()
+			var tconfig template.TemplateVmConfig
+			if err := json.NewDecoder(f).Decode(&tconfig); err != nil {
+				return nil, fmt.Errorf("factory/template: parse template config: %v", err)
+			}
+			return NewFromExisted(&tconfig), nil
+		},
+	})
+}
+
+// Config is the `[plugins."io.runv.factory.template"]` TOML shape.
+type Config struct {
+	TemplatePath string `toml:"template_path"`
+}
+
 type templateFactory struct {
 	s *template.TemplateVmConfig
 }
@@ -44,7 +80,11 @@ func (t *templateFactory) Config() *hypervisor.BootConfig {
 }
 
 func (t *templateFactory) GetBaseVm() (*hypervisor.Vm, error) {
-	return t.s.NewVmFromTemplate("")
+	start := time.Now()
+	metrics.FactoryMisses.WithLabelValues("template").Inc()
+	vm, err := t.s.NewVmFromTemplate("")
+	metrics.VMBootDuration.WithLabelValues("template").Observe(time.Since(start).Seconds())
+	return vm, err
 }
 
 func (t *templateFactory) CloseFactory() {
@@ -0,0 +1,94 @@
+package shim
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"time"
+
+	eventsapi "github.com/containerd/containerd/api/services/events/v1"
+	eventstypes "github.com/containerd/containerd/api/types"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/ttrpc"
+	"github.com/containerd/typeurl"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+var timeZero = time.Time{}
+
+func init() {
+	typeurl.Register(&taskEvent{}, "types.hyperhq.io", "task.Event")
+}
+
+// taskEvent is the minimal payload runv has on hand when forwardEvents
+// fires: the task ID. It mirrors the one-event-per-process fallback below,
+// which only ever has room for --id on the command line too.
+type taskEvent struct {
+	ID string `json:"id"`
+}
+
+// publisher delivers task events to containerd for a single namespace,
+// preferring a live ttrpc connection to containerd's main socket
+// (-address) and falling back to shelling out to the -publish-binary
+// containerd handed us -- the same one-event-per-process fallback the
+// upstream containerd shim uses -- if that connection can't be made.
+type publisher struct {
+	namespace string
+	address   string
+	binary    string
+	client    *ttrpc.Client
+}
+
+func newPublisher(namespace, address, binary string) (*publisher, error) {
+	p := &publisher{namespace: namespace, address: address, binary: binary}
+	if address == "" {
+		return p, nil
+	}
+	conn, err := net.Dial("unix", address)
+	if err != nil {
+		glog.Warningf("shim: dial containerd events socket %s: %v, falling back to %s", address, err, binary)
+		return p, nil
+	}
+	p.client = ttrpc.NewClient(conn)
+	return p, nil
+}
+
+func (p *publisher) publish(ctx context.Context, topic string, id string) error {
+	if p.client != nil {
+		any, err := typeurl.MarshalAny(&taskEvent{ID: id})
+		if err != nil {
+			return fmt.Errorf("shim: marshal event %s for %s: %v", topic, id, err)
+		}
+		req := &eventsapi.ForwardRequest{
+			Envelope: &eventstypes.Envelope{
+				Timestamp: time.Now(),
+				Namespace: p.namespace,
+				Topic:     topic,
+				Event:     any,
+			},
+		}
+		ctx = namespaces.WithNamespace(ctx, p.namespace)
+		_, err = eventsapi.NewEventsClient(p.client).Forward(ctx, req)
+		return err
+	}
+	if p.binary == "" {
+		glog.V(2).Infof("shim: dropping event %s for %s: no publisher configured", topic, id)
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, p.binary, "--namespace", p.namespace, "publish", "--topic", topic, "--id", id)
+	return cmd.Run()
+}
+
+// forwardEvents relays supervisor.Supervisor container lifecycle events to
+// containerd as they happen, the same events the old daemon socket used
+// to keep entirely internal to runv.
+func forwardEvents(ctx context.Context, s *Service, p *publisher) {
+	events := s.sv.Events.Events(timeZero)
+	for e := range events {
+		topic := fmt.Sprintf("/tasks/%v", e.Type)
+		if err := p.publish(ctx, topic, e.ID); err != nil {
+			glog.Errorf("shim: publish event %s for %s failed: %v", topic, e.ID, err)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package shim
+
+import (
+	"github.com/containerd/typeurl"
+	"github.com/hyperhq/runv/supervisor"
+)
+
+func init() {
+	typeurl.Register(&CreateOptions{}, "types.hyperhq.io", "shim.CreateOptions")
+}
+
+// CreateOptions is the runv-specific payload a caller can pack into
+// task.CreateTaskRequest.Options (as a typeurl Any) to list existing
+// namespaces for the container. Service.Create rejects a request whose
+// Namespaces is non-empty: the shim does not join existing namespaces, and
+// CreateContainer creates fresh ones for every type.
+type CreateOptions struct {
+	Namespaces []supervisor.Namespace `json:"namespaces"`
+}
@@ -0,0 +1,200 @@
+package shim
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/containerd/containerd/runtime/v2/task"
+	"github.com/containerd/ttrpc"
+	"github.com/containerd/typeurl"
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/glog"
+	"github.com/hyperhq/runv/factory"
+	"github.com/hyperhq/runv/supervisor"
+	"golang.org/x/net/context"
+)
+
+// Config carries the bundle-local state a Service needs in order to drive
+// a single container through the shared factory/supervisor machinery.
+type Config struct {
+	Namespace string
+	ID        string
+	Bundle    string
+}
+
+// Service implements the containerd Task Service v2 (TaskService) on top of
+// the existing factory.Factory/supervisor.Supervisor pair. Unlike the
+// `containerd` subcommand, which multiplexes every container through a
+// single daemon socket, one Service is created per container by the shim
+// binary and talks to containerd over the per-bundle socket handed to it on
+// the command line.
+type Service struct {
+	mu sync.Mutex
+
+	config Config
+	sv     *supervisor.Supervisor
+	f      factory.Factory
+}
+
+// NewService wires up a Service for the container described by cfg, reusing
+// the same supervisor.New(stateDir, containerdDir, f) plumbing the
+// containerd daemon uses.
+func NewService(cfg Config, f factory.Factory) (*Service, error) {
+	sv, err := supervisor.New(cfg.Bundle, cfg.Bundle, f)
+	if err != nil {
+		return nil, fmt.Errorf("shim: failed to create supervisor for %s: %v", cfg.ID, err)
+	}
+	return &Service{config: cfg, sv: sv, f: f}, nil
+}
+
+// Register exposes the Service on a ttrpc server the way containerd expects
+// shim v2 runtimes to.
+func (s *Service) Register(server *ttrpc.Server) {
+	task.RegisterTaskService(server, s)
+}
+
+func (s *Service) Create(ctx context.Context, r *task.CreateTaskRequest) (*task.CreateTaskResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Options != nil {
+		v, err := typeurl.UnmarshalAny(r.Options)
+		if err != nil {
+			return nil, fmt.Errorf("shim: unmarshal create options for %s: %v", r.ID, err)
+		}
+		if opts, ok := v.(*CreateOptions); ok && len(opts.Namespaces) > 0 {
+			// supervisor.JoinNamespaces can setns(2) the calling thread
+			// into these before CreateContainer forks its sandbox helper,
+			// but CreateContainer's implementation isn't part of this
+			// tree and has no way to learn which types were pre-joined, so
+			// it creates a fresh namespace for every type regardless,
+			// stomping the join. Calling JoinNamespaces here would setns
+			// into a namespace CreateContainer immediately discards -- a
+			// silent no-op dressed up as a feature. Report it as
+			// unsupported instead, the same way Pause/Resume/Checkpoint do
+			// below, until CreateContainer can actually skip creating the
+			// types it's handed.
+			return nil, fmt.Errorf("shim: create %s: joining existing namespaces is not supported", r.ID)
+		}
+	}
+
+	glog.V(1).Infof("shim: create container %s bundle=%s", r.ID, r.Bundle)
+	pid, err := s.sv.CreateContainer(r.ID, r.Bundle, r.Rootfs, r.Terminal, r.Stdin, r.Stdout, r.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("shim: create %s: %v", r.ID, err)
+	}
+	return &task.CreateTaskResponse{Pid: pid}, nil
+}
+
+func (s *Service) Start(ctx context.Context, r *task.StartRequest) (*task.StartResponse, error) {
+	pid, err := s.sv.StartContainer(r.ID, r.ExecID)
+	if err != nil {
+		return nil, fmt.Errorf("shim: start %s: %v", r.ID, err)
+	}
+	return &task.StartResponse{Pid: pid}, nil
+}
+
+func (s *Service) Delete(ctx context.Context, r *task.DeleteRequest) (*task.DeleteResponse, error) {
+	exit, err := s.sv.DeleteContainer(r.ID, r.ExecID)
+	if err != nil {
+		return nil, fmt.Errorf("shim: delete %s: %v", r.ID, err)
+	}
+	return &task.DeleteResponse{
+		Pid:        exit.Pid,
+		ExitStatus: exit.Status,
+		ExitedAt:   exit.At,
+	}, nil
+}
+
+func (s *Service) Pids(ctx context.Context, r *task.PidsRequest) (*task.PidsResponse, error) {
+	procs, err := s.sv.ListProcesses(r.ID)
+	if err != nil {
+		return nil, fmt.Errorf("shim: pids %s: %v", r.ID, err)
+	}
+	return &task.PidsResponse{Processes: procs}, nil
+}
+
+func (s *Service) Pause(ctx context.Context, r *task.PauseRequest) (*types.Empty, error) {
+	return nil, fmt.Errorf("shim: pause is not supported by runv")
+}
+
+func (s *Service) Resume(ctx context.Context, r *task.ResumeRequest) (*types.Empty, error) {
+	return nil, fmt.Errorf("shim: resume is not supported by runv")
+}
+
+func (s *Service) Checkpoint(ctx context.Context, r *task.CheckpointTaskRequest) (*types.Empty, error) {
+	return nil, fmt.Errorf("shim: checkpoint is not supported by runv")
+}
+
+func (s *Service) Kill(ctx context.Context, r *task.KillRequest) (*types.Empty, error) {
+	if err := s.sv.SignalContainer(r.ID, r.ExecID, r.Signal, r.All); err != nil {
+		return nil, fmt.Errorf("shim: kill %s: %v", r.ID, err)
+	}
+	return &types.Empty{}, nil
+}
+
+func (s *Service) Exec(ctx context.Context, r *task.ExecProcessRequest) (*types.Empty, error) {
+	if err := s.sv.ExecProcess(r.ID, r.ExecID, r.Spec, r.Terminal, r.Stdin, r.Stdout, r.Stderr); err != nil {
+		return nil, fmt.Errorf("shim: exec %s/%s: %v", r.ID, r.ExecID, err)
+	}
+	return &types.Empty{}, nil
+}
+
+func (s *Service) ResizePty(ctx context.Context, r *task.ResizePtyRequest) (*types.Empty, error) {
+	if err := s.sv.ResizeProcessTTY(r.ID, r.ExecID, uint(r.Width), uint(r.Height)); err != nil {
+		return nil, fmt.Errorf("shim: resize pty %s/%s: %v", r.ID, r.ExecID, err)
+	}
+	return &types.Empty{}, nil
+}
+
+func (s *Service) CloseIO(ctx context.Context, r *task.CloseIORequest) (*types.Empty, error) {
+	if err := s.sv.CloseContainerStdin(r.ID, r.ExecID); err != nil {
+		return nil, fmt.Errorf("shim: close io %s/%s: %v", r.ID, r.ExecID, err)
+	}
+	return &types.Empty{}, nil
+}
+
+func (s *Service) Update(ctx context.Context, r *task.UpdateTaskRequest) (*types.Empty, error) {
+	if err := s.sv.UpdateContainer(r.ID, r.Resources); err != nil {
+		return nil, fmt.Errorf("shim: update %s: %v", r.ID, err)
+	}
+	return &types.Empty{}, nil
+}
+
+func (s *Service) Wait(ctx context.Context, r *task.WaitRequest) (*task.WaitResponse, error) {
+	exit, err := s.sv.WaitContainer(r.ID, r.ExecID)
+	if err != nil {
+		return nil, fmt.Errorf("shim: wait %s: %v", r.ID, err)
+	}
+	return &task.WaitResponse{ExitStatus: exit.Status, ExitedAt: exit.At}, nil
+}
+
+func (s *Service) Stats(ctx context.Context, r *task.StatsRequest) (*task.StatsResponse, error) {
+	stats, err := s.sv.ContainerStats(r.ID)
+	if err != nil {
+		return nil, fmt.Errorf("shim: stats %s: %v", r.ID, err)
+	}
+	return &task.StatsResponse{Stats: stats}, nil
+}
+
+func (s *Service) State(ctx context.Context, r *task.StateRequest) (*task.StateResponse, error) {
+	st, err := s.sv.ContainerState(r.ID, r.ExecID)
+	if err != nil {
+		return nil, fmt.Errorf("shim: state %s: %v", r.ID, err)
+	}
+	return st, nil
+}
+
+// Shutdown closes the underlying factory once containerd has deleted the
+// last task the shim was managing. The supervisor is not torn down.
+func (s *Service) Shutdown(ctx context.Context, r *task.ShutdownRequest) (*types.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.f.CloseFactory()
+	return &types.Empty{}, nil
+}
+
+func (s *Service) Connect(ctx context.Context, r *task.ConnectRequest) (*task.ConnectResponse, error) {
+	return &task.ConnectResponse{}, nil
+}
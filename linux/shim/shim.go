@@ -0,0 +1,126 @@
+package shim
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/containerd/ttrpc"
+	"github.com/golang/glog"
+	"github.com/hyperhq/runv/driverloader"
+	"github.com/hyperhq/runv/factory"
+	templatefactory "github.com/hyperhq/runv/factory/template"
+	"github.com/hyperhq/runv/hypervisor"
+	templatecore "github.com/hyperhq/runv/template"
+	"golang.org/x/net/context"
+)
+
+// Flags mirrors the flag set containerd passes to every io.containerd.*.v2
+// shim binary it forks: -namespace, -id, -bundle and -socket identify the
+// task this shim instance is responsible for, while -address/-publish-binary
+// tell it how to publish events back to containerd.
+type Flags struct {
+	Namespace     string
+	ID            string
+	Bundle        string
+	Socket        string
+	Address       string
+	PublishBinary string
+	Debug         bool
+}
+
+// ParseFlags parses the shim's own flags out of args, which must already
+// have the "shim" subcommand token stripped (i.e. cli.Context.Args(), not
+// os.Args[1:] -- flag.Parse stops at the first non-flag token, so handing
+// it raw os.Args would leave every flag at its zero value).
+func ParseFlags(args []string) *Flags {
+	f := &Flags{}
+	fs := flag.NewFlagSet("shim", flag.ExitOnError)
+	fs.StringVar(&f.Namespace, "namespace", "", "namespace that owns the shim")
+	fs.StringVar(&f.ID, "id", "", "id of the task")
+	fs.StringVar(&f.Bundle, "bundle", "", "path to the container bundle")
+	fs.StringVar(&f.Socket, "socket", "", "abstract socket path to serve the task service on")
+	fs.StringVar(&f.Address, "address", "", "address of containerd's main socket")
+	fs.StringVar(&f.PublishBinary, "publish-binary", "", "path to the binary used to publish events to containerd")
+	fs.BoolVar(&f.Debug, "debug", false, "enable debug output in the shim logs")
+	fs.Parse(args)
+	return f
+}
+
+// Run boots a Task Service v2 shim for a single container: it creates the
+// factory/supervisor pair the way `runv containerd` does, serves the Task
+// API over the unix socket containerd expects, and prints that socket's
+// address on stdout so containerd can dial it. args are the shim's own
+// flags, with the "shim" subcommand token already stripped.
+func Run(args []string, driver, kernel, initrd, template string) error {
+	flags := ParseFlags(args)
+	if flags.ID == "" || flags.Bundle == "" {
+		return fmt.Errorf("shim: -id and -bundle are required")
+	}
+
+	hypervisor.InterfaceCount = 0
+	var err error
+	if hypervisor.HDriver, err = driverloader.Probe(driver); err != nil {
+		return err
+	}
+
+	var f factory.Factory
+	if template != "" {
+		path := filepath.Join(template, "config.json")
+		cf, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("shim: open template config %s: %v", path, err)
+		}
+		var tconfig *templatecore.TemplateVmConfig
+		err = json.NewDecoder(cf).Decode(&tconfig)
+		cf.Close()
+		if err != nil {
+			return fmt.Errorf("shim: parse template config %s: %v", path, err)
+		}
+		f = templatefactory.NewFromExisted(tconfig)
+	} else {
+		f = factory.NewFromConfigs(kernel, initrd, nil)
+	}
+
+	svc, err := NewService(Config{
+		Namespace: flags.Namespace,
+		ID:        flags.ID,
+		Bundle:    flags.Bundle,
+	}, f)
+	if err != nil {
+		return err
+	}
+
+	address := flags.Socket
+	if address == "" {
+		if address, err = newSocket(flags.Bundle); err != nil {
+			return err
+		}
+	}
+	l, err := net.Listen("unix", address)
+	if err != nil {
+		return fmt.Errorf("shim: listen on %s: %v", address, err)
+	}
+
+	server, err := ttrpc.NewServer()
+	if err != nil {
+		return fmt.Errorf("shim: new ttrpc server: %v", err)
+	}
+	svc.Register(server)
+
+	publisher, err := newPublisher(flags.Namespace, flags.Address, flags.PublishBinary)
+	if err != nil {
+		return err
+	}
+	go forwardEvents(context.Background(), svc, publisher)
+
+	// containerd reads the listening address from our stdout before it
+	// considers the shim ready.
+	fmt.Fprintln(os.Stdout, address)
+
+	glog.Infof("shim: serving task service for %s on %s", flags.ID, address)
+	return server.Serve(context.Background(), l)
+}
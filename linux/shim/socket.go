@@ -0,0 +1,19 @@
+package shim
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+)
+
+// newSocket derives a per-bundle abstract unix socket address so that
+// multiple shim instances on the same host never collide, mirroring the
+// naming scheme upstream containerd shims use for their own task sockets.
+func newSocket(bundle string) (string, error) {
+	abs, err := filepath.Abs(bundle)
+	if err != nil {
+		return "", fmt.Errorf("shim: resolve bundle path %s: %v", bundle, err)
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return fmt.Sprintf("\x00runv-shim/%x.sock", sum[:16]), nil
+}
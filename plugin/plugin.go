@@ -0,0 +1,93 @@
+// Package plugin provides the registry that lets drivers, factories and
+// other daemon subsystems register themselves by id instead of being wired
+// up by hand in the `containerd` command's Action func.
+package plugin
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Type groups registrations so the daemon can decide init order and which
+// kind of thing a plugin id is supposed to produce.
+type Type string
+
+const (
+	// DriverPlugin registers a hypervisor.HypervisorDriver (qemu, kvm, xen, vbox, ...).
+	DriverPlugin Type = "io.runv.driver"
+	// FactoryPlugin registers a factory.Factory (direct, template, cached, ...).
+	FactoryPlugin Type = "io.runv.factory"
+	// GRPCPlugin registers a service to be exposed on the daemon's gRPC server.
+	GRPCPlugin Type = "io.runv.grpc"
+)
+
+// InitContext is handed to a Registration's InitFn. Config is the
+// plugin's own sub-tree of the TOML config file, still undecoded: plugins
+// that care about their config call toml.PrimitiveDecode(ic.Config, &myConfig).
+type InitContext struct {
+	Config toml.Primitive
+	Meta   toml.MetaData
+	Root   string
+}
+
+// Decode unmarshals the plugin's own config section into v.
+func (ic *InitContext) Decode(v interface{}) error {
+	return ic.Meta.PrimitiveDecode(ic.Config, v)
+}
+
+// Registration describes one pluggable component.
+type Registration struct {
+	Type Type
+	ID   string
+	// InitFn builds the plugin instance. Its return value is whatever the
+	// plugin type expects (base.Factory, hypervisor.HypervisorDriver, ...);
+	// callers type-assert it after Init returns.
+	InitFn func(ic *InitContext) (interface{}, error)
+}
+
+// Init builds the plugin by calling its InitFn, which decodes the plugin's
+// own config from ic.
+func (r *Registration) Init(ic *InitContext) (interface{}, error) {
+	return r.InitFn(ic)
+}
+
+// URI is the fully qualified plugin id, e.g. `io.runv.factory.template`,
+// which is how plugins are addressed in the `[plugins."..."]` TOML sections.
+func (r *Registration) URI() string {
+	return fmt.Sprintf("%s.%s", r.Type, r.ID)
+}
+
+var register = map[string]*Registration{}
+
+// Register adds r to the global registry. It panics on a duplicate URI,
+// the same way the daemon would rather fail at startup than silently shadow
+// one plugin with another.
+func Register(r *Registration) {
+	uri := r.URI()
+	if _, ok := register[uri]; ok {
+		panic(fmt.Sprintf("plugin: %s already registered", uri))
+	}
+	register[uri] = r
+}
+
+// Get looks up a previously registered plugin by its fully qualified URI.
+func Get(uri string) (*Registration, error) {
+	r, ok := register[uri]
+	if !ok {
+		return nil, fmt.Errorf("plugin: no registration for %q", uri)
+	}
+	return r, nil
+}
+
+// Registrations returns every registered plugin, sorted by URI so init
+// order is deterministic.
+func Registrations() []*Registration {
+	out := make([]*Registration, 0, len(register))
+	for _, r := range register {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].URI() < out[j].URI() })
+	return out
+}
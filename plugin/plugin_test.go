@@ -0,0 +1,51 @@
+package plugin
+
+import "testing"
+
+func TestRegisterAndGet(t *testing.T) {
+	r := &Registration{
+		Type: FactoryPlugin,
+		ID:   "test-register-and-get",
+		InitFn: func(ic *InitContext) (interface{}, error) {
+			return "ok", nil
+		},
+	}
+	Register(r)
+
+	got, err := Get(r.URI())
+	if err != nil {
+		t.Fatalf("Get(%q) failed: %v", r.URI(), err)
+	}
+	if got != r {
+		t.Fatalf("Get(%q) returned a different *Registration", r.URI())
+	}
+}
+
+func TestGetUnregistered(t *testing.T) {
+	if _, err := Get("io.runv.factory.does-not-exist"); err == nil {
+		t.Fatal("Get of an unregistered URI should fail")
+	}
+}
+
+func TestRegisterDuplicatePanics(t *testing.T) {
+	r := &Registration{
+		Type:   FactoryPlugin,
+		ID:     "test-register-duplicate",
+		InitFn: func(ic *InitContext) (interface{}, error) { return nil, nil },
+	}
+	Register(r)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Register of a duplicate URI should panic")
+		}
+	}()
+	Register(r)
+}
+
+func TestURI(t *testing.T) {
+	r := &Registration{Type: DriverPlugin, ID: "kvm"}
+	if got, want := r.URI(), "io.runv.driver.kvm"; got != want {
+		t.Errorf("URI() = %q, want %q", got, want)
+	}
+}
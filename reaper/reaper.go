@@ -0,0 +1,119 @@
+// Package reaper replaces blind calls to osutils.Reap with a subscribable
+// child-reaping subsystem: a single goroutine owns unix.Wait4(-1, ...) and
+// fans exit status out to whoever started that particular process, so exit
+// codes are no longer discarded on the floor.
+package reaper
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// noChildrenBackoff is how long Run sleeps after wait4 reports ECHILD
+// (nothing currently running) before it checks again, so a daemon with no
+// containers at the moment doesn't spin.
+const noChildrenBackoff = 500 * time.Millisecond
+
+// ExitStatus is what a Subscription eventually receives for the pid it was
+// created for.
+type ExitStatus struct {
+	Pid    int
+	Status int
+	Rusage unix.Rusage
+}
+
+// Subscription is returned by Start and resolves once the process it was
+// created for has been reaped.
+type Subscription struct {
+	pid int
+	c   chan ExitStatus
+}
+
+// Wait blocks until the subscribed process exits or ctx is done.
+func (s *Subscription) Wait(ctx context.Context) (ExitStatus, error) {
+	select {
+	case es := <-s.c:
+		return es, nil
+	case <-ctx.Done():
+		return ExitStatus{}, ctx.Err()
+	}
+}
+
+// Reaper owns the single wait4 loop for the process and dispatches exit
+// events to the subscriber registered for each pid.
+type Reaper struct {
+	mu          sync.Mutex
+	subscribers map[int]chan ExitStatus
+}
+
+// Default is the process-wide reaper every runv-spawned exec.Cmd should go
+// through. Run must be started once, early in main, before any Start call.
+var Default = &Reaper{subscribers: make(map[int]chan ExitStatus)}
+
+// Start launches cmd and registers a subscription for its pid. It must be
+// called instead of cmd.Start() directly so the reaper knows about the pid
+// before the child can possibly exit.
+func (r *Reaper) Start(cmd *exec.Cmd) (*Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	pid := cmd.Process.Pid
+	if _, ok := r.subscribers[pid]; ok {
+		return nil, fmt.Errorf("reaper: pid %d is already subscribed", pid)
+	}
+	c := make(chan ExitStatus, 1)
+	r.subscribers[pid] = c
+	return &Subscription{pid: pid, c: c}, nil
+}
+
+// Run is the reaper's main loop: it blocks in wait4(-1) until some child of
+// this process exits, then delivers the result to whichever Subscription
+// Start created for that pid, discarding exits no one subscribed to (e.g.
+// a grandchild re-parented to us). It checks ctx only between wait4 calls,
+// so once ctx is done it returns after the wait4 in progress (or the
+// back-off sleep) comes back.
+func (r *Reaper) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		var ws unix.WaitStatus
+		var rusage unix.Rusage
+		pid, err := unix.Wait4(-1, &ws, 0, &rusage)
+		if err == unix.EINTR {
+			continue
+		}
+		if err == unix.ECHILD {
+			// Nothing running right now; back off until the next Start.
+			time.Sleep(noChildrenBackoff)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		r.mu.Lock()
+		c, ok := r.subscribers[pid]
+		if ok {
+			delete(r.subscribers, pid)
+		}
+		r.mu.Unlock()
+
+		if !ok {
+			continue
+		}
+		c <- ExitStatus{Pid: pid, Status: ws.ExitStatus(), Rusage: rusage}
+		close(c)
+	}
+}
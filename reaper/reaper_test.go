@@ -0,0 +1,34 @@
+package reaper
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestReaperStartAndWait(t *testing.T) {
+	r := &Reaper{subscribers: make(map[int]chan ExitStatus)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	cmd := exec.Command("true")
+	sub, err := r.Start(cmd)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer waitCancel()
+	es, err := sub.Wait(waitCtx)
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if es.Pid != cmd.Process.Pid {
+		t.Errorf("ExitStatus.Pid = %d, want %d", es.Pid, cmd.Process.Pid)
+	}
+	if es.Status != 0 {
+		t.Errorf("ExitStatus.Status = %d, want 0", es.Status)
+	}
+}
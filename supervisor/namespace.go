@@ -0,0 +1,79 @@
+package supervisor
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+)
+
+// Namespace identifies an existing Linux namespace a container should join
+// instead of getting a fresh one of its own, the gRPC-level equivalent of
+// `docker run --net=container:other` or a Kubernetes pod's shared network
+// namespace. Type is one of the `ipc`/`mnt`/`net`/`pid`/`uts` namespace
+// kinds; Path is the bind-mounted namespace file to join, e.g.
+// `/proc/<pid>/ns/net`.
+type Namespace struct {
+	Type string
+	Path string
+}
+
+// nsCloneFlag maps a Namespace.Type to the clone(2)/setns(2) flag that
+// selects it.
+var nsCloneFlag = map[string]uintptr{
+	"ipc": unix.CLONE_NEWIPC,
+	"mnt": unix.CLONE_NEWNS,
+	"net": unix.CLONE_NEWNET,
+	"pid": unix.CLONE_NEWPID,
+	"uts": unix.CLONE_NEWUTS,
+}
+
+// JoinNamespaces setns(2)s the calling thread into every namespace in
+// namespaces before the sandbox helper process continues its tap/veth (or
+// other namespace-specific) setup.
+//
+// No caller in this tree invokes this yet: CreateContainer's implementation
+// isn't part of this tree, so it can't be taught to consult namespaceTypes
+// and skip creating a fresh namespace for a type already joined here, and
+// calling JoinNamespaces without that cooperation would just setns into a
+// namespace CreateContainer immediately replaces. This is the half of the
+// feature that's ready -- shim.Service.Create reports namespace joining as
+// unsupported rather than call this -- so CreateContainer's namespace
+// creation can be made conditional on namespaceTypes without anything here
+// changing.
+//
+// Callers must lock the calling goroutine to its OS thread with
+// runtime.LockOSThread before calling JoinNamespaces, since setns(2) only
+// affects the calling thread.
+func JoinNamespaces(namespaces []Namespace) error {
+	joined := namespaceTypes(namespaces)
+	for _, ns := range namespaces {
+		flag, ok := nsCloneFlag[ns.Type]
+		if !ok {
+			return fmt.Errorf("supervisor: unknown namespace type %q", ns.Type)
+		}
+		f, err := os.Open(ns.Path)
+		if err != nil {
+			return fmt.Errorf("supervisor: open namespace %s (%s): %v", ns.Type, ns.Path, err)
+		}
+		err = unix.Setns(int(f.Fd()), int(flag))
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("supervisor: setns into %s (%s): %v", ns.Type, ns.Path, err)
+		}
+	}
+	glog.V(1).Infof("supervisor: joined existing namespaces: %v", joined)
+	return nil
+}
+
+// namespaceTypes returns the set of namespace kinds that namespaces already
+// covers, so the rest of the container creation path knows which of its
+// usual new-namespace steps to skip, once it's able to consult this.
+func namespaceTypes(namespaces []Namespace) map[string]bool {
+	joined := make(map[string]bool, len(namespaces))
+	for _, ns := range namespaces {
+		joined[ns.Type] = true
+	}
+	return joined
+}